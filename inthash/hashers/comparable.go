@@ -0,0 +1,18 @@
+//go:build go1.24
+
+package hashers
+
+import "hash/maphash"
+
+// comparableSeed is process-global and randomized once at startup, like
+// String's and Bytes's seeds in hashers.go.
+var comparableSeed = maphash.MakeSeed()
+
+// Comparable hashes any comparable value structurally via
+// hash/maphash.Comparable (added in Go 1.24), so it works for struct and
+// array keys too, not just strings and scalars. It's slower than String,
+// Bytes or Uint64 for the key types those cover; prefer one of them when
+// the key type is known and one applies.
+func Comparable[T comparable](v T) uint64 {
+	return maphash.Comparable(comparableSeed, v)
+}
@@ -0,0 +1,41 @@
+// Package hashers ships small, ready-made hash functions for use with
+// segmentedmap.NewSegmentedMapWithHasher / inthash.NewFromFunc, for key
+// types where the built-in reflect- or kind-based hashers in inthash cost
+// more than a caller-supplied one would.
+package hashers
+
+import (
+	"hash/maphash"
+
+	"segmentedmap/inthash"
+)
+
+// stringSeed and bytesSeed are process-global and randomized once at
+// startup, like inthash's own reflectSeed, so String and Bytes are stable
+// within a process but not predictable across runs.
+var stringSeed = maphash.MakeSeed()
+var bytesSeed = maphash.MakeSeed()
+
+// String hashes s with maphash, seeded once per process.
+func String(s string) uint64 {
+	return maphash.Bytes(stringSeed, inthash.StringToBytes(s))
+}
+
+// Bytes hashes b with maphash, seeded once per process (a seed distinct
+// from String's, so equal contents don't hash the same across the two).
+func Bytes(b []byte) uint64 {
+	return maphash.Bytes(bytesSeed, b)
+}
+
+// Uint64 mixes the bits of v with the splitmix64 finalizer. It's a cheap,
+// allocation-free hash well suited to keys that are already unique or
+// already well distributed (e.g. sequential or random IDs), where a
+// cryptographic or seeded hash would just add overhead.
+func Uint64(v uint64) uint64 {
+	v ^= v >> 30
+	v *= 0xbf58476d1ce4e5b9
+	v ^= v >> 27
+	v *= 0x94d049bb133111eb
+	v ^= v >> 31
+	return v
+}
@@ -0,0 +1,54 @@
+package hashers
+
+import (
+	"fmt"
+	"testing"
+
+	"segmentedmap/inthash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString(t *testing.T) {
+	assert.Equal(t, String("abc"), String("abc"))
+	assert.NotEqual(t, String("abc"), String("abd"))
+}
+
+func TestBytes(t *testing.T) {
+	assert.Equal(t, Bytes([]byte("abc")), Bytes([]byte("abc")))
+	assert.NotEqual(t, Bytes([]byte("abc")), Bytes([]byte("abd")))
+}
+
+func TestUint64(t *testing.T) {
+	assert.Equal(t, Uint64(42), Uint64(42))
+	assert.NotEqual(t, Uint64(42), Uint64(43))
+}
+
+// BenchmarkReflectiveStringSegments128 measures inthash's reflect-dispatched
+// hasher on string keys at 128 segments under concurrent load.
+func BenchmarkReflectiveStringSegments128(b *testing.B) {
+	h := inthash.New(128)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_ = h.Get(fmt.Sprintf("key-%d", i))
+			i++
+		}
+	})
+}
+
+// BenchmarkMaphashStringSegments128 measures hashers.String plugged in via
+// inthash.NewFromFunc on string keys at 128 segments under concurrent
+// load, for comparison against BenchmarkReflectiveStringSegments128.
+func BenchmarkMaphashStringSegments128(b *testing.B) {
+	h := inthash.NewFromFunc[string](128, String)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_ = h.Get(fmt.Sprintf("key-%d", i))
+			i++
+		}
+	})
+}
@@ -3,14 +3,29 @@ package segmentedmap
 import (
 	hash "segmentedmap/inthash"
 	"sync"
+	"sync/atomic"
 )
 
+// defaultLoadFactor is the Len()/segments ratio that, once crossed after a
+// Set, triggers an automatic doubling of the segment count (see
+// NewGrowingSegmentedMap).
+const defaultLoadFactor = 8
+
 // Map is a thread-safe map divided into segments, provides high concurrent performance
-type Map[K hash.Hashable, V any] struct {
-	segment   []map[K]V       // Map segments
-	mutex     []*sync.RWMutex // Mutexes for synchronizing access
-	hash      *hash.Hash[K]   // Hash generation struct
-	segmented bool            // If map has more than 1 segment
+type Map[K comparable, V any] struct {
+	genMu       sync.RWMutex    // guards segment/mutex/oldSegment/oldMutex/migrateAt during a grow
+	segment     []map[K]V       // current generation's segments
+	mutex       []*sync.RWMutex // mutexes for segment
+	oldSegment  []map[K]V       // previous generation, non-nil only while a grow is migrating
+	oldMutex    []*sync.RWMutex // mutexes for oldSegment
+	migrateAt   atomic.Int64    // next oldSegment index migrateStep should claim
+	migrateDone atomic.Int64    // count of oldSegment buckets fully migrated so far
+	count       atomic.Int64    // number of entries in the map, kept in sync by mutate/Delete
+
+	hash       *hash.Hash[K]     // Hash generation struct
+	segmented  bool              // If map has more than 1 segment
+	eq         func(a, b V) bool // Value comparator used by CompareAndSwap/CompareAndDelete
+	loadFactor int               // Len()/segments threshold that triggers a grow; 0 disables it
 }
 
 // NewMap creates a new thread-safe map
@@ -39,93 +54,288 @@ func NewSegmentedMap[K hash.Hashable, V any](segmentCount int, segmentCapacity i
 	}
 }
 
+// NewGrowingSegmentedMap creates a thread-safe map like NewSegmentedMap, but
+// one that doubles its segment count on its own as it fills up: once
+// Len()/segments crosses loadFactor after a Set, the segment count doubles
+// and entries migrate into the new, larger layout incrementally across
+// subsequent Set/Get/Delete calls rather than all at once. loadFactor <= 0
+// uses defaultLoadFactor.
+func NewGrowingSegmentedMap[K hash.Hashable, V any](segmentCount int, segmentCapacity int, loadFactor int) *Map[K, V] {
+	m := NewSegmentedMap[K, V](segmentCount, segmentCapacity)
+	if loadFactor <= 0 {
+		loadFactor = defaultLoadFactor
+	}
+	m.loadFactor = loadFactor
+	return m
+}
+
+// NewSegmentedMapWithHasher creates a thread-safe map divided into
+// segments that hashes keys with hasher instead of inthash's built-in
+// reflect- or kind-based dispatch. Use this when K is expensive to hash
+// reflectively (long strings, large structs) or a cheap hash is already
+// available (e.g. a precomputed request ID); see the inthash/hashers
+// subpackage for some ready-made hasher functions.
+func NewSegmentedMapWithHasher[K comparable, V any](segmentCount int, segmentCapacity int, hasher func(K) uint64) *Map[K, V] {
+	segmented := false
+	hash := hash.NewFromFunc[K](segmentCount, hasher)
+	if hash.Segments() > 1 {
+		segmented = true
+	}
+	segments := make([]map[K]V, segmentCount)
+	mutex := make([]*sync.RWMutex, segmentCount)
+	for i := range segments {
+		segments[i] = make(map[K]V, segmentCapacity)
+		mutex[i] = &sync.RWMutex{}
+	}
+	return &Map[K, V]{
+		segment:   segments,
+		hash:      hash,
+		mutex:     mutex,
+		segmented: segmented,
+	}
+}
+
+// NewSegmentedDeepMap creates a thread-safe map divided into segments, keyed
+// by a deep hash of K. Unlike NewSegmentedMap, K isn't restricted to
+// hash.Hashable scalars: any comparable type is accepted, including struct
+// and array keys, as long as hash.NewDeepHash can walk its layout (chan,
+// func, map and interface fields are not supported). Two structurally equal
+// keys always land in the same segment.
+func NewSegmentedDeepMap[K comparable, V any](segmentCount int, segmentCapacity int) *Map[K, V] {
+	segmented := false
+	deepHash := hash.NewDeepHash[K](segmentCount)
+	if deepHash.Segments() > 1 {
+		segmented = true
+	}
+	segments := make([]map[K]V, segmentCount)
+	mutex := make([]*sync.RWMutex, segmentCount)
+	for i := range segments {
+		segments[i] = make(map[K]V, segmentCapacity)
+		mutex[i] = &sync.RWMutex{}
+	}
+	return &Map[K, V]{
+		segment:   segments,
+		hash:      deepHash,
+		mutex:     mutex,
+		segmented: segmented,
+	}
+}
+
+// NewMapFunc creates a new thread-safe map whose CompareAndSwap and
+// CompareAndDelete compare values using eq.
+func NewMapFunc[K hash.Hashable, V any](eq func(a, b V) bool) *Map[K, V] {
+	return NewSegmentedMapFunc[K, V](1, 1, eq)
+}
+
+// NewSegmentedMapFunc creates a thread-safe map divided into segments whose
+// CompareAndSwap and CompareAndDelete compare values using eq, since V is
+// any and can't be compared with ==.
+func NewSegmentedMapFunc[K hash.Hashable, V any](segmentCount int, segmentCapacity int, eq func(a, b V) bool) *Map[K, V] {
+	m := NewSegmentedMap[K, V](segmentCount, segmentCapacity)
+	m.eq = eq
+	return m
+}
+
+// locateLocked returns the live segment/mutex slices for both generations
+// along with k's index in each, and whether a grow is currently migrating.
+// The caller must hold genMu (read lock is enough).
+func (s *Map[K, V]) locateLocked(k K) (seg []map[K]V, mtx []*sync.RWMutex, oldSeg []map[K]V, oldMtx []*sync.RWMutex, idx, oldIdx int, growing bool) {
+	seg, mtx = s.segment, s.mutex
+	oldSeg, oldMtx = s.oldSegment, s.oldMutex
+	idx = s.hash.Get(k)
+	growing = oldSeg != nil
+	if growing {
+		oldIdx = s.hash.GetAt(k, len(oldSeg))
+	}
+	return
+}
+
+// mutate runs fn against k's current value, falling back to the
+// not-yet-migrated old generation's value while a grow is in progress, and
+// applies whatever fn decides: write a new value, delete the key, or leave
+// it alone. It takes the old segment's lock first, then the new segment's
+// (lock-ordering old-then-new), so it can never race with migrateStep
+// moving the same key out from under it. A key found only in the old
+// generation is always promoted into the new one, even if fn leaves its
+// value unchanged, so it isn't silently lost once migration clears the old
+// bucket.
+func (s *Map[K, V]) mutate(k K, fn func(current V, ok bool) (next V, write bool, del bool)) {
+	s.genMu.RLock()
+	defer s.genMu.RUnlock()
+	s.mutateLocked(k, fn)
+}
+
+// mutateLocked is mutate's body, for callers that already hold genMu (a
+// read lock is enough). Delete needs this: it has to decide whether a grow
+// is in progress and act on that decision without releasing genMu in
+// between, or a grow starting in the gap would leave it acting on a stale
+// answer (see Delete).
+func (s *Map[K, V]) mutateLocked(k K, fn func(current V, ok bool) (next V, write bool, del bool)) {
+	seg, mtx, oldSeg, oldMtx, idx, oldIdx, growing := s.locateLocked(k)
+
+	if growing {
+		oldMtx[oldIdx].Lock()
+		defer oldMtx[oldIdx].Unlock()
+	}
+	mtx[idx].Lock()
+	defer mtx[idx].Unlock()
+
+	current, ok := seg[idx][k]
+	fromOld := false
+	if !ok && growing {
+		current, ok = oldSeg[oldIdx][k]
+		fromOld = ok
+	}
+
+	next, write, del := fn(current, ok)
+
+	if growing && fromOld {
+		delete(oldSeg[oldIdx], k)
+	}
+	switch {
+	case del:
+		if ok {
+			s.count.Add(-1)
+		}
+		delete(seg[idx], k)
+	case write:
+		if !ok {
+			s.count.Add(1)
+		}
+		seg[idx][k] = next
+	case fromOld:
+		seg[idx][k] = current
+	}
+}
+
 // Set inserts or updates the value for the given key into map.
 func (s *Map[K, V]) Set(k K, v V) {
-	h := s.hash.Get(k)
-	s.lock(h)
-	defer s.unlock(h)
-	s.segment[h][k] = v
+	s.migrateStep()
+	s.mutate(k, func(V, bool) (V, bool, bool) {
+		return v, true, false
+	})
+	s.maybeGrow()
 }
 
 // Get retrieves the value associated with the given key.
 // It returns the value and a boolean indicating whether the key exists.
 func (s *Map[K, V]) Get(k K) (V, bool) {
-	h := s.hash.Get(k)
-	s.rLock(h)
-	defer s.rUnlock(h)
-	if v, ok := s.segment[h][k]; ok {
-		return v, ok
-	} else {
-		return zero[V](), false
+	s.migrateStep()
+
+	s.genMu.RLock()
+	defer s.genMu.RUnlock()
+
+	seg, mtx, oldSeg, oldMtx, idx, oldIdx, growing := s.locateLocked(k)
+
+	// Old-then-new, same ordering as mutate, and both held at once: checking
+	// one generation, releasing its lock, and only then checking the other
+	// would leave a window where migrateStep could move the key out of the
+	// one just released and into the one not yet checked, so neither check
+	// sees it.
+	if growing {
+		oldMtx[oldIdx].RLock()
+		defer oldMtx[oldIdx].RUnlock()
+	}
+	mtx[idx].RLock()
+	defer mtx[idx].RUnlock()
+
+	if v, ok := seg[idx][k]; ok {
+		return v, true
+	}
+	if growing {
+		if v, ok := oldSeg[oldIdx][k]; ok {
+			return v, true
+		}
 	}
+	return zero[V](), false
 }
 
 // Delete removes the key-value.
 func (s *Map[K, V]) Delete(keys ...K) {
-	hashes := make(map[int][]K, max(len(keys), s.hash.Segments()))
-	for _, k := range keys {
-		hashes[s.hash.Get(k)] = append(hashes[s.hash.Get(k)], k)
-	}
-	for h, hashKeys := range hashes {
-		s.lock(h)
-		for _, k := range hashKeys {
-			delete(s.segment[h], k)
+	s.migrateStep()
+
+	s.genMu.RLock()
+	defer s.genMu.RUnlock()
+
+	if s.oldSegment == nil {
+		hashes := make(map[int][]K, max(len(keys), s.hash.Segments()))
+		for _, k := range keys {
+			hashes[s.hash.Get(k)] = append(hashes[s.hash.Get(k)], k)
 		}
-		s.unlock(h)
+		for h, hashKeys := range hashes {
+			s.mutex[h].Lock()
+			for _, k := range hashKeys {
+				if _, ok := s.segment[h][k]; ok {
+					delete(s.segment[h], k)
+					s.count.Add(-1)
+				}
+			}
+			s.mutex[h].Unlock()
+		}
+		return
+	}
+
+	// Route through mutateLocked (genMu is already held above, so mutate
+	// itself would self-deadlock if a grow were waiting on it) instead of
+	// re-checking s.oldSegment after releasing genMu: a grow starting in
+	// that gap would make the check above stale, and this branch would go
+	// on deleting from s.segment while the key was still sitting unmigrated
+	// in the new s.oldSegment.
+	for _, k := range keys {
+		s.mutateLocked(k, func(V, bool) (V, bool, bool) {
+			return zero[V](), false, true
+		})
 	}
 }
 
 // DeleteConditional retrieves the value associated with the key and computes condition value.
 // It returns the value removed or zero and a boolean indicating whether a key is exist.
 func (s *Map[K, V]) DeleteConditional(k K, calculate func(v V) bool) (v V, existing bool) {
-	h := s.hash.Get(k)
-	s.lock(h)
-	defer s.unlock(h)
-
-	if v, ok := s.segment[h][k]; ok {
-		condition := calculate(v)
-		if condition {
-			delete(s.segment[h], k)
+	s.migrateStep()
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		if !ok {
+			v, existing = zero[V](), false
+			return zero[V](), false, false
 		}
-		return v, false
-	} else {
-		return zero[V](), false
-	}
+		v, existing = current, true
+		if calculate(current) {
+			return zero[V](), false, true
+		}
+		return current, false, false
+	})
+	return v, existing
 }
 
-// Len returns the number of key-value pairs in the map.
+// Len returns the number of key-value pairs in the map. It's backed by an
+// atomic counter kept in sync by mutate and Delete, so it's O(1) regardless
+// of segment count, rather than locking and summing every segment.
 func (s *Map[K, V]) Len() int {
-	_len := 0
-	for i := range s.segment {
-		s.rLock(i)
-		_len = _len + len(s.segment[i])
-		s.rUnlock(i)
-	}
-	return _len
+	return int(s.count.Load())
 }
 
-// Keys return all keys from safe map
+// Keys returns all keys from the map. It's built on Snapshot, so it never
+// holds a segment's lock for longer than the time it takes to copy that
+// segment's entries (see Snapshot for the consistency this gives you).
 func (s *Map[K, V]) Keys() []K {
-	keys := make([]K, 0, len(s.segment[0]))
-	for i := range s.segment {
-		s.rLock(i)
-		for k := range s.segment[i] {
-			keys = append(keys, k)
-		}
-		s.rUnlock(i)
+	snap := s.Snapshot()
+	keys := make([]K, 0, snap.Len())
+	for snap.Next() {
+		k, _ := snap.Entry()
+		keys = append(keys, k)
 	}
 	return keys
 }
 
-// Keys return all values from safe map
+// Values returns all values from the map. It's built on Snapshot, so it
+// never holds a segment's lock for longer than the time it takes to copy
+// that segment's entries (see Snapshot for the consistency this gives
+// you).
 func (s *Map[K, V]) Values() []V {
-	values := make([]V, 0, len(s.segment[0]))
-	for i := range s.segment {
-		s.rLock(i)
-		for _, v := range s.segment[i] {
-			values = append(values, v)
-		}
-		s.rUnlock(i)
+	snap := s.Snapshot()
+	values := make([]V, 0, snap.Len())
+	for snap.Next() {
+		_, v := snap.Entry()
+		values = append(values, v)
 	}
 	return values
 }
@@ -133,117 +343,251 @@ func (s *Map[K, V]) Values() []V {
 // GetOrSet retrieves the value associated with the key, or computes and stores a new value if the key does not exist.
 // It returns the value and a boolean indicating whether a new value was created.
 func (s *Map[K, V]) GetOrSet(k K, calculate func() V) (v V, created bool) {
-	if v, ok := s.Get(k); ok {
-		return v, false
+	s.migrateStep()
+
+	if existing, ok := s.Get(k); ok {
+		return existing, false
 	}
 
-	h := s.hash.Get(k)
-	s.lock(h)
-	defer s.unlock(h)
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		if ok {
+			v, created = current, false
+			return current, false, false
+		}
+		v = calculate()
+		created = true
+		return v, true, false
+	})
+	return v, created
+}
+
+// Swap stores v for the given key and returns the previous value, if any.
+// It returns the previous value and a boolean indicating whether the key
+// previously existed.
+func (s *Map[K, V]) Swap(k K, v V) (previous V, loaded bool) {
+	s.migrateStep()
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		previous, loaded = current, ok
+		return v, true, false
+	})
+	return previous, loaded
+}
 
-	if v, ok := s.segment[h][k]; ok {
-		return v, false
-	}
+// CompareAndSwap updates the value for the key to new only if its current
+// value compares equal to old under the comparator supplied at
+// construction (see NewSegmentedMapFunc). It returns whether the swap took
+// place; it is false if the key doesn't exist or its value doesn't compare
+// equal to old.
+func (s *Map[K, V]) CompareAndSwap(k K, old, new V) bool {
+	s.requireEq()
+	s.migrateStep()
+
+	var swapped bool
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		if !ok || !s.eq(current, old) {
+			return current, false, false
+		}
+		swapped = true
+		return new, true, false
+	})
+	return swapped
+}
 
-	v = calculate()
-	s.segment[h][k] = v
+// CompareAndDelete removes the key-value pair for the key only if its
+// current value compares equal to old under the comparator supplied at
+// construction (see NewSegmentedMapFunc). It returns whether the key was
+// deleted.
+func (s *Map[K, V]) CompareAndDelete(k K, old V) bool {
+	s.requireEq()
+	s.migrateStep()
+
+	var deleted bool
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		if !ok || !s.eq(current, old) {
+			return current, false, false
+		}
+		deleted = true
+		return zero[V](), false, true
+	})
+	return deleted
+}
 
-	return v, true
+// requireEq panics if the map wasn't constructed with a value comparator,
+// since CompareAndSwap/CompareAndDelete have no other way to compare V.
+func (s *Map[K, V]) requireEq() {
+	if s.eq == nil {
+		panic("segmentedmap: CompareAndSwap/CompareAndDelete require a comparator; construct the map with NewMapFunc or NewSegmentedMapFunc")
+	}
 }
 
 // GetAndDelete retrieves the value associated with the key if it exists and delete the key from the map.
 // It returns the value and a boolean indicating whether the key is exists.
 func (s *Map[K, V]) GetAndDelete(k K) (v V, exist bool) {
-	h := s.hash.Get(k)
-	s.lock(h)
-	defer s.unlock(h)
-
-	if v, ok := s.segment[h][k]; ok {
-		delete(s.segment[h], k)
-		return v, true
-	}
-
-	return zero[V](), false
+	s.migrateStep()
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		v, exist = current, ok
+		if !ok {
+			return zero[V](), false, false
+		}
+		return zero[V](), false, true
+	})
+	return v, exist
 }
 
 // Update retrieves the value associated with the key and computes new value based on the previous one.
 // If value does not exist it uses the provided one to perform the calculation. Return the new values and a boolean indicating whether a new value was created.
 func (s *Map[K, V]) Update(k K, defaultValue V, calculate func(V) V) (v V, created bool) {
-	h := s.hash.Get(k)
-	s.lock(h)
-	defer s.unlock(h)
-
-	if v, ok := s.segment[h][k]; ok {
-		value := calculate(v)
-		s.segment[h][k] = value
-		return value, false
-	} else {
-		value := calculate(defaultValue)
-		s.segment[h][k] = value
-		return value, true
-	}
+	s.migrateStep()
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		if ok {
+			v = calculate(current)
+			created = false
+		} else {
+			v = calculate(defaultValue)
+			created = true
+		}
+		return v, true, false
+	})
+	return v, created
 }
 
 // Update retrieves the value associated with the key and computes new values based on the previous one.
 // If value does not exist it do nothing. Return the new values and a boolean indicating whether the key is exists.
 func (s *Map[K, V]) UpdateExisting(k K, calculate func(V) V) (v V, exist bool) {
-	h := s.hash.Get(k)
-	s.lock(h)
-	defer s.unlock(h)
-
-	if v, ok := s.segment[h][k]; ok {
-		value := calculate(v)
-		s.segment[h][k] = value
-		return value, true
-	}
-
-	return zero[V](), false
+	s.migrateStep()
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		if !ok {
+			return zero[V](), false, false
+		}
+		v = calculate(current)
+		exist = true
+		return v, true, false
+	})
+	return v, exist
 }
 
 // Calculate retrieves the value associated with the key and computes some value.
 // If value does not exist it returns nil. Returns the calculated valuer and a boolean indicating whether the key is exists.
 func (s *Map[K, V]) Calculate(k K, calculate func(V) any) (r any, exist bool) {
-	h := s.hash.Get(k)
-	s.lock(h)
-	defer s.unlock(h)
-
-	if v, ok := s.segment[h][k]; ok {
-		value := calculate(v)
-		return value, true
-	}
-
-	return nil, false
+	s.migrateStep()
+	s.mutate(k, func(current V, ok bool) (V, bool, bool) {
+		if !ok {
+			return zero[V](), false, false
+		}
+		r = calculate(current)
+		exist = true
+		return current, false, false
+	})
+	return r, exist
 }
 
-// Range iterates over all key-value pairs in the map, applying the given function.
-// If the function returns false, the iteration stops.
+// Range iterates over all key-value pairs in the map, applying the given
+// function. If the function returns false, the iteration stops. Unlike
+// Snapshot/RangeSegment, Range holds each segment's RLock for as long as
+// run takes on that segment's entries, so a slow run blocks writers to
+// that segment; use Snapshot or RangeSegment if that's a problem.
 func (s *Map[K, V]) Range(run func(k K, v V) bool) {
+	s.genMu.RLock()
+	defer s.genMu.RUnlock()
+
 	for i := range s.segment {
-		s.rLock(i)
+		s.mutex[i].RLock()
 		for k, v := range s.segment[i] {
 			if ok := run(k, v); !ok { // Stop iteration if the callback returns false
-				s.rUnlock(i)
+				s.mutex[i].RUnlock()
 				return
 			}
 		}
-		s.rUnlock(i)
+		s.mutex[i].RUnlock()
+	}
+	for i := range s.oldSegment {
+		s.oldMutex[i].RLock()
+		for k, v := range s.oldSegment[i] {
+			if ok := run(k, v); !ok {
+				s.oldMutex[i].RUnlock()
+				return
+			}
+		}
+		s.oldMutex[i].RUnlock()
 	}
 }
 
-func (s *Map[K, V]) rLock(i int) {
-	s.mutex[i].RLock()
-}
+// maybeGrow doubles the segment count once Len()/segments crosses
+// loadFactor. It's a no-op for maps created without a load factor (see
+// NewGrowingSegmentedMap). The actual data migration isn't done here: it
+// happens incrementally in migrateStep, called from Set/Get/Delete, so a
+// grow never pauses the whole map at once.
+func (s *Map[K, V]) maybeGrow() {
+	if s.loadFactor <= 0 {
+		return
+	}
+	segments := s.hash.Segments()
+	if s.Len()/segments <= s.loadFactor {
+		return
+	}
 
-func (s *Map[K, V]) rUnlock(i int) {
-	s.mutex[i].RUnlock()
-}
+	s.genMu.Lock()
+	defer s.genMu.Unlock()
+
+	if s.oldSegment != nil || s.hash.Segments() != segments {
+		return // another goroutine already started (or finished) a grow
+	}
 
-func (s *Map[K, V]) lock(i int) {
-	s.mutex[i].Lock()
+	newSegment := make([]map[K]V, segments*2)
+	newMutex := make([]*sync.RWMutex, segments*2)
+	for i := range newSegment {
+		newSegment[i] = make(map[K]V)
+		newMutex[i] = &sync.RWMutex{}
+	}
+
+	s.oldSegment = s.segment
+	s.oldMutex = s.mutex
+	s.segment = newSegment
+	s.mutex = newMutex
+	s.migrateAt.Store(0)
+	s.migrateDone.Store(0)
+	s.hash.Grow()
+	s.segmented = true
 }
 
-func (s *Map[K, V]) unlock(i int) {
-	s.mutex[i].Unlock()
+// migrateStep migrates one old segment's worth of entries into the new
+// generation, if a grow is in progress. Called at the start of Set, Get
+// and Delete so the migration completes incrementally across ordinary
+// traffic instead of pausing the whole map.
+func (s *Map[K, V]) migrateStep() {
+	s.genMu.RLock()
+	oldSeg, oldMtx := s.oldSegment, s.oldMutex
+	newSeg, newMtx := s.segment, s.mutex
+	s.genMu.RUnlock()
+
+	if oldSeg == nil {
+		return
+	}
+	i := int(s.migrateAt.Add(1)) - 1
+	if i >= len(oldSeg) {
+		return
+	}
+
+	oldMtx[i].Lock()
+	for k, v := range oldSeg[i] {
+		newIdx := s.hash.Get(k)
+		newMtx[newIdx].Lock()
+		newSeg[newIdx][k] = v
+		newMtx[newIdx].Unlock()
+		delete(oldSeg[i], k)
+	}
+	oldMtx[i].Unlock()
+
+	if int(s.migrateDone.Add(1)) == len(oldSeg) {
+		s.genMu.Lock()
+		if len(s.oldSegment) == len(oldSeg) {
+			s.oldSegment = nil
+			s.oldMutex = nil
+			s.migrateAt.Store(0)
+			s.migrateDone.Store(0)
+		}
+		s.genMu.Unlock()
+	}
 }
 
 func zero[T any]() (x T) {
@@ -1,9 +1,12 @@
 package segmentedmap
 
 import (
+	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -121,7 +124,7 @@ func TestKeys(t *testing.T) {
 	m.Set(2, "value2")
 
 	keys = m.Keys()
-	assert.Equal(t, []int{2, 1}, keys)
+	assert.ElementsMatch(t, []int{1, 2}, keys)
 }
 
 func TestValues(t *testing.T) {
@@ -138,7 +141,7 @@ func TestValues(t *testing.T) {
 	m.Set(2, "value2")
 
 	values = m.Values()
-	assert.Equal(t, []string{"value2", "value1"}, values)
+	assert.ElementsMatch(t, []string{"value1", "value2"}, values)
 }
 
 func TestGetOrSet(t *testing.T) {
@@ -168,6 +171,101 @@ func TestGetOrSet(t *testing.T) {
 	}
 }
 
+func intEq(a, b int) bool { return a == b }
+
+func TestSwap(t *testing.T) {
+	m := NewMap[int, string]()
+
+	previous, loaded := m.Swap(1, "value1")
+	assert.False(t, loaded)
+	assert.Equal(t, "", previous)
+
+	previous, loaded = m.Swap(1, "value2")
+	assert.True(t, loaded)
+	assert.Equal(t, "value1", previous)
+
+	value, ok := m.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, "value2", value)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	m := NewMapFunc[int, int](intEq)
+	m.Set(1, 10)
+
+	assert.False(t, m.CompareAndSwap(1, 11, 20))
+	value, ok := m.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 10, value)
+
+	assert.True(t, m.CompareAndSwap(1, 10, 20))
+	value, ok = m.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 20, value)
+
+	assert.False(t, m.CompareAndSwap(2, 0, 1))
+
+	// segmented
+	m = NewSegmentedMapFunc[int, int](2, 1, intEq)
+	m.Set(1, 10)
+	assert.True(t, m.CompareAndSwap(1, 10, 20))
+	value, ok = m.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 20, value)
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	m := NewMapFunc[int, int](intEq)
+	m.Set(1, 10)
+
+	assert.False(t, m.CompareAndDelete(1, 11))
+	_, ok := m.Get(1)
+	assert.True(t, ok)
+
+	assert.True(t, m.CompareAndDelete(1, 10))
+	_, ok = m.Get(1)
+	assert.False(t, ok)
+
+	assert.False(t, m.CompareAndDelete(1, 10))
+}
+
+func TestCompareAndSwapWithoutComparatorPanics(t *testing.T) {
+	m := NewMap[int, int]()
+	assert.Panics(t, func() { m.CompareAndSwap(1, 0, 1) })
+}
+
+func TestCompareAndSwapConcurrentRace(t *testing.T) {
+	m := NewSegmentedMapFunc[int, int](8, 1, intEq)
+	m.Set(1, 0)
+
+	var wg sync.WaitGroup
+	var successes int64
+	goroutines := 1000
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				current, ok := m.Get(1)
+				if !ok {
+					continue
+				}
+				if m.CompareAndSwap(1, current, current+1) {
+					atomic.AddInt64(&successes, 1)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(goroutines), successes)
+	value, ok := m.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, goroutines, value)
+}
+
 func TestGetAndDelete(t *testing.T) {
 	m := NewMap[int, string]()
 	m.Set(1, "value1")
@@ -320,6 +418,52 @@ func BenchmarkSetGet(b *testing.B) {
 	})
 }
 
+type tenantKey struct {
+	Tenant string
+	ID     int
+}
+
+func TestSegmentedDeepMap(t *testing.T) {
+	m := NewSegmentedDeepMap[tenantKey, string](4, 1)
+
+	k1 := tenantKey{Tenant: "acme", ID: 1}
+	m.Set(k1, "value1")
+
+	// A structurally equal but distinct key value must land in the same
+	// segment and retrieve the same value.
+	k2 := tenantKey{Tenant: "acme", ID: 1}
+	value, ok := m.Get(k2)
+	assert.True(t, ok)
+	assert.Equal(t, "value1", value)
+
+	_, ok = m.Get(tenantKey{Tenant: "acme", ID: 2})
+	assert.False(t, ok)
+}
+
+func TestSegmentedMapWithHasher(t *testing.T) {
+	m := NewSegmentedMapWithHasher[string, string](4, 1, func(k string) uint64 {
+		var h uint64
+		for i := 0; i < len(k); i++ {
+			h = h*31 + uint64(k[i])
+		}
+		return h
+	})
+
+	m.Set("a", "value1")
+	m.Set("b", "value2")
+
+	value, ok := m.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "value1", value)
+
+	value, ok = m.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, "value2", value)
+
+	_, ok = m.Get("c")
+	assert.False(t, ok)
+}
+
 func BenchmarkSetGetSegmented(b *testing.B) {
 	m := NewSegmentedMap[int, string](128, 1)
 	numberGoroutine := 1
@@ -333,3 +477,200 @@ func BenchmarkSetGetSegmented(b *testing.B) {
 		}
 	})
 }
+
+func TestGrowingSegmentedMapGrows(t *testing.T) {
+	m := NewGrowingSegmentedMap[int, string](2, 1, 2)
+
+	entries := 50
+	for i := range entries {
+		m.Set(i, "v"+strconv.Itoa(i))
+	}
+
+	assert.Greater(t, m.hash.Segments(), 2)
+	assert.Equal(t, entries, m.Len())
+
+	for i := range entries {
+		value, ok := m.Get(i)
+		assert.True(t, ok)
+		assert.Equal(t, "v"+strconv.Itoa(i), value)
+	}
+
+	keys := m.Keys()
+	assert.Len(t, keys, entries)
+	values := m.Values()
+	assert.Len(t, values, entries)
+
+	for i := range entries {
+		m.Delete(i)
+		_, ok := m.Get(i)
+		assert.False(t, ok)
+	}
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestGrowingSegmentedMapConcurrentAccess(t *testing.T) {
+	m := NewGrowingSegmentedMap[int, string](2, 1, 4)
+	var wg sync.WaitGroup
+
+	numberGoroutine := 5000
+	for i := range numberGoroutine {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.Set(i, "v"+strconv.Itoa(i))
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range numberGoroutine {
+		value, ok := m.Get(i)
+		assert.True(t, ok)
+		assert.Equal(t, "v"+strconv.Itoa(i), value)
+	}
+	assert.Equal(t, numberGoroutine, m.Len())
+}
+
+// TestGrowingSegmentedMapConcurrentDeleteDuringMigration races Delete
+// against Set and GetOrSet on overlapping keys while a grow is actively
+// migrating entries. Delete used to take the old segment's lock, delete,
+// and release it before taking the new segment's lock for the same key --
+// unlike mutate, which every other mutator goes through and which holds
+// both locks together -- leaving a window where a write from one of those
+// other methods could land in between and then be silently wiped out by
+// Delete's second phase. Len is backed by the same counter that Delete and
+// mutate both maintain, so a write Delete dropped without accounting for,
+// or wiped without having observed, shows up here as Len() disagreeing with
+// what Keys() can still find.
+func TestGrowingSegmentedMapConcurrentDeleteDuringMigration(t *testing.T) {
+	m := NewGrowingSegmentedMap[int, string](2, 1, 2)
+
+	keys := 500
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			m.Set(i, "v"+strconv.Itoa(i))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			m.Delete(i)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = m.GetOrSet(i, func() string { return "created" + strconv.Itoa(i) })
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, m.Len(), len(m.Keys()))
+	for _, k := range m.Keys() {
+		_, ok := m.Get(k)
+		assert.True(t, ok)
+	}
+}
+
+// TestGetDuringMigrationNoFalseNegative deterministically forces Get to run
+// while a key is mid-relocation from the old generation to the new one,
+// instead of relying on incidental goroutine scheduling to hit the window.
+// It holds the old bucket's lock across the whole simulated relocation
+// (the same write-then-delete migrateStep does) and only starts Get once
+// that lock is already held, so Get cannot complete either generation's
+// check until the relocation is finished, no matter which order it runs
+// them in. Get used to check the new segment, release that lock, and only
+// then check the old segment in a second, separate critical section; a
+// relocation landing in that gap made it miss the key in both checks.
+func TestGetDuringMigrationNoFalseNegative(t *testing.T) {
+	m := NewGrowingSegmentedMap[int, string](1, 1, 1)
+	m.Set(1, "v1")
+	m.Set(2, "v2") // crosses the load factor, growing m to 2 segments
+	assert.NotNil(t, m.oldSegment)
+
+	// Get also drives migrateStep on every call; exhaust it up front so it
+	// can't contend for the old bucket's lock we're about to hold, which
+	// would otherwise serialize Get's own migrateStep call behind our
+	// relocation instead of racing against it as intended.
+	m.migrateAt.Store(int64(len(m.oldSegment)))
+
+	key := 1
+	newIdx := m.hash.Get(key)
+	oldIdx := m.hash.GetAt(key, len(m.oldSegment))
+
+	m.oldMutex[oldIdx].Lock()
+	m.mutex[newIdx].Lock()
+
+	done := make(chan struct{})
+	var v string
+	var ok bool
+	go func() {
+		v, ok = m.Get(key)
+		close(done)
+	}()
+
+	// Let Get's new-segment check run and see a genuine miss (nothing has
+	// been relocated yet) before the relocation below happens, so the race
+	// is actually exercised regardless of which generation Get checks
+	// first.
+	m.mutex[newIdx].Unlock()
+	time.Sleep(10 * time.Millisecond)
+
+	// Simulate migrateStep relocating the key while still holding the lock
+	// Get needs before it can look at either generation.
+	m.mutex[newIdx].Lock()
+	m.segment[newIdx][key] = "v1"
+	m.mutex[newIdx].Unlock()
+	delete(m.oldSegment[oldIdx], key)
+
+	m.oldMutex[oldIdx].Unlock()
+	<-done
+
+	assert.True(t, ok)
+	assert.Equal(t, "v1", v)
+}
+
+// TestDeleteHoldsGenMuThroughGrowCheck deterministically verifies that
+// Delete's decision of whether a grow is in progress and the deletion it
+// performs as a result happen under one continuously-held genMu.RLock(),
+// rather than across two separate acquisitions with a gap in between. It
+// blocks Delete mid-call by pre-locking the bucket mutex it needs, then
+// polls genMu.TryLock() (what maybeGrow needs to start a grow) to confirm
+// it cannot be granted once Delete is observed holding it -- which is
+// only possible if Delete's RLock is still held at that point, since
+// nothing else in this test touches genMu. A prior fix released genMu
+// between computing that decision and acting on it, which let a grow
+// starting in the gap strand the key, unmigrated, in the new oldSegment.
+func TestDeleteHoldsGenMuThroughGrowCheck(t *testing.T) {
+	m := NewGrowingSegmentedMap[int, string](1, 1, 1)
+	m.Set(42, "v")
+
+	idx := m.hash.Get(42)
+	m.mutex[idx].Lock()
+
+	done := make(chan struct{})
+	go func() {
+		m.Delete(42)
+		close(done)
+	}()
+
+	// Give Delete time to start and run up to wherever it naturally blocks.
+	// It cannot possibly finish before we release mutex[idx] below, so for
+	// the whole polling window it must still be "in progress" -- if it's
+	// holding genMu continuously across its decision and its action, as it
+	// should, genMu.TryLock() must fail for the entire window.
+	time.Sleep(5 * time.Millisecond)
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if m.genMu.TryLock() {
+			m.genMu.Unlock()
+			t.Fatal("genMu.Lock() succeeded while Delete was still in progress")
+		}
+		runtime.Gosched()
+	}
+
+	m.mutex[idx].Unlock()
+	<-done
+
+	_, ok := m.Get(42)
+	assert.False(t, ok)
+	assert.Equal(t, 0, m.Len())
+}
@@ -0,0 +1,96 @@
+package segmentedmap
+
+// mapEntry is one (key, value) pair captured by Snapshot.
+type mapEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// Snapshot is a weakly-consistent, point-in-time view over a Map's
+// entries, returned by Map.Snapshot. Entries present for the whole
+// snapshot window are guaranteed to be visited exactly once; one inserted
+// or deleted while the snapshot was being taken may or may not appear,
+// and, while a grow is migrating entries into a larger segment layout,
+// one may briefly appear twice (once from the new generation, once from
+// the not-yet-cleared old one).
+type Snapshot[K comparable, V any] struct {
+	entries []mapEntry[K, V]
+	pos     int
+}
+
+// Next advances the snapshot to its next entry, returning false once
+// there are none left.
+func (it *Snapshot[K, V]) Next() bool {
+	if it.pos >= len(it.entries) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+// Entry returns the key and value at the iterator's current position.
+// It's only valid to call after a call to Next that returned true.
+func (it *Snapshot[K, V]) Entry() (K, V) {
+	e := it.entries[it.pos-1]
+	return e.key, e.value
+}
+
+// Len returns the number of entries captured in the snapshot.
+func (it *Snapshot[K, V]) Len() int {
+	return len(it.entries)
+}
+
+// Snapshot takes a weakly-consistent snapshot of the map's current
+// contents: each segment's RLock is held only long enough to copy its
+// entries into the snapshot's own buffer, then released, so taking a
+// snapshot never blocks writers for longer than a single segment copy.
+// See the Snapshot type for the consistency guarantees this gives callers.
+func (s *Map[K, V]) Snapshot() *Snapshot[K, V] {
+	s.genMu.RLock()
+	seg, mtx := s.segment, s.mutex
+	oldSeg, oldMtx := s.oldSegment, s.oldMutex
+	s.genMu.RUnlock()
+
+	// The old generation is copied before the new one: entries only ever move
+	// from old to new, so a key migrated while the snapshot is being taken is
+	// caught by the later copy of the new generation instead of being missed.
+	entries := make([]mapEntry[K, V], 0, len(seg))
+	for i := range oldSeg {
+		oldMtx[i].RLock()
+		for k, v := range oldSeg[i] {
+			entries = append(entries, mapEntry[K, V]{k, v})
+		}
+		oldMtx[i].RUnlock()
+	}
+	for i := range seg {
+		mtx[i].RLock()
+		for k, v := range seg[i] {
+			entries = append(entries, mapEntry[K, V]{k, v})
+		}
+		mtx[i].RUnlock()
+	}
+	return &Snapshot[K, V]{entries: entries}
+}
+
+// RangeSegment iterates over a single segment's entries, applying fn the
+// same way Range does, but only takes that segment's RLock rather than
+// the whole map's. It lets callers parallelize iteration by running one
+// goroutine per segment index, from 0 up to (not including) the map's
+// current segment count. While a grow is
+// migrating entries into a larger layout, a segment reached through
+// RangeSegment only sees what's already landed in its current generation
+// slot; entries still waiting in the old generation aren't visited here
+// (Range and Snapshot do cover them).
+func (s *Map[K, V]) RangeSegment(segmentIdx int, fn func(k K, v V) bool) {
+	s.genMu.RLock()
+	seg, mtx := s.segment, s.mutex
+	s.genMu.RUnlock()
+
+	mtx[segmentIdx].RLock()
+	defer mtx[segmentIdx].RUnlock()
+	for k, v := range seg[segmentIdx] {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
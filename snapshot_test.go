@@ -0,0 +1,82 @@
+package segmentedmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	m := NewSegmentedMap[int, string](4, 1)
+	m.Set(1, "value1")
+	m.Set(2, "value2")
+	m.Set(3, "value3")
+
+	snap := m.Snapshot()
+	assert.Equal(t, 3, snap.Len())
+
+	got := make(map[int]string, snap.Len())
+	for snap.Next() {
+		k, v := snap.Entry()
+		got[k] = v
+	}
+	assert.Equal(t, map[int]string{1: "value1", 2: "value2", 3: "value3"}, got)
+
+	// Exhausted.
+	assert.False(t, snap.Next())
+}
+
+func TestSnapshotEmpty(t *testing.T) {
+	m := NewSegmentedMap[int, string](4, 1)
+	snap := m.Snapshot()
+	assert.Equal(t, 0, snap.Len())
+	assert.False(t, snap.Next())
+}
+
+func TestSnapshotDuringGrowth(t *testing.T) {
+	m := NewGrowingSegmentedMap[int, string](2, 1, 2)
+	entries := 100
+	for i := 0; i < entries; i++ {
+		m.Set(i, "v")
+	}
+
+	// A snapshot taken once the map has settled must see every key at
+	// least once.
+	snap := m.Snapshot()
+	seen := make(map[int]bool, entries)
+	for snap.Next() {
+		k, _ := snap.Entry()
+		seen[k] = true
+	}
+	for i := 0; i < entries; i++ {
+		assert.True(t, seen[i], "missing key %d", i)
+	}
+}
+
+func TestRangeSegment(t *testing.T) {
+	m := NewSegmentedMap[int, string](4, 1)
+	m.Set(1, "value1")
+	m.Set(2, "value2")
+
+	var got []int
+	for i := 0; i < m.hash.Segments(); i++ {
+		m.RangeSegment(i, func(k int, v string) bool {
+			got = append(got, k)
+			return true
+		})
+	}
+	assert.ElementsMatch(t, []int{1, 2}, got)
+}
+
+func TestRangeSegmentStopsEarly(t *testing.T) {
+	m := NewSegmentedMap[int, string](1, 1)
+	m.Set(1, "value1")
+	m.Set(2, "value2")
+
+	calls := 0
+	m.RangeSegment(0, func(k int, v string) bool {
+		calls++
+		return false
+	})
+	assert.Equal(t, 1, calls)
+}